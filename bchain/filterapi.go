@@ -0,0 +1,99 @@
+package bchain
+
+import (
+	"encoding/hex"
+
+	"github.com/juju/errors"
+)
+
+// MaxFilterHeadersPerRequest caps the number of headers returned by a single
+// getblockfilterheaders call, matching the BIP 157 getcfheaders limit
+const MaxFilterHeadersPerRequest = 2000
+
+// GetBlockFilterResult is the response of the getblockfilter RPC/REST endpoint
+type GetBlockFilterResult struct {
+	FilterType uint8  `json:"filterType"`
+	BlockHash  string `json:"blockHash"`
+	Filter     string `json:"filter"`
+}
+
+// GetBlockFilterHeaderResult is the response of the getblockfilterheader RPC/REST endpoint
+type GetBlockFilterHeaderResult struct {
+	FilterType uint8  `json:"filterType"`
+	BlockHash  string `json:"blockHash"`
+	Header     string `json:"header"`
+}
+
+// GetBlockFilterHeadersResult is the response of the getblockfilterheaders range endpoint,
+// returning up to MaxFilterHeadersPerRequest sequential headers starting at the requested height
+type GetBlockFilterHeadersResult struct {
+	FilterType  uint8    `json:"filterType"`
+	StartHeight uint32   `json:"startHeight"`
+	StopHash    string   `json:"stopHash"`
+	Headers     []string `json:"headers"`
+}
+
+// NewFilterSubscriptionResult is pushed over the websocket subscription whenever
+// the chain tip advances and a new (filter, header) pair is computed for it
+type NewFilterSubscriptionResult struct {
+	Height    uint32 `json:"height"`
+	BlockHash string `json:"blockHash"`
+	Filter    string `json:"filter"`
+	Header    string `json:"header"`
+}
+
+// ScanBlocksRequest is the request of the scanblocks endpoint: a wallet's
+// watch-set of address descriptors and the height range to rescan
+type ScanBlocksRequest struct {
+	FilterType  uint8               `json:"filterType"`
+	Descriptors []AddressDescriptor `json:"descriptors"`
+	StartHeight uint32              `json:"startHeight"`
+	StopHeight  uint32              `json:"stopHeight"`
+}
+
+// ScanBlocksResult is the response of the scanblocks endpoint: the heights
+// whose stored filter matched at least one of the requested descriptors
+type ScanBlocksResult struct {
+	Heights []uint32 `json:"heights"`
+}
+
+// ScanBlocks matches a watch-set of address descriptors against a sequence of
+// stored filters, one per scanned block, and returns the heights that
+// matched at least one descriptor. The caller passes the stored entries for
+// the scanned heights; entries of another filterType are skipped. Each
+// entry's filter is matched with the SipHash key derived from its own BlockHash (see BlockFilterKey), since
+// a BIP 158 filter key is per-block, not shared across the scanned range
+func ScanBlocks(filterType uint8, p uint8, m uint64, descriptors []AddressDescriptor, entries []*FilterHeaderEntry) (*ScanBlocksResult, error) {
+	items := make([][]byte, len(descriptors))
+	for i, ad := range descriptors {
+		items[i] = ad
+	}
+	result := &ScanBlocksResult{Heights: make([]uint32, 0)}
+	for _, entry := range entries {
+		if entry.FilterType != filterType {
+			continue
+		}
+		key, err := BlockFilterKey(entry.BlockHash)
+		if err != nil {
+			return nil, errors.Annotatef(err, "block %s at height %d", entry.BlockHash, entry.Height)
+		}
+		matched, err := MatchFilter(entry.Filter, key, p, m, items)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			result.Heights = append(result.Heights, entry.Height)
+		}
+	}
+	return result, nil
+}
+
+// NewFilterSubscriptionResultFromEntry builds the websocket push payload from a FilterHeaderEntry
+func NewFilterSubscriptionResultFromEntry(entry *FilterHeaderEntry) *NewFilterSubscriptionResult {
+	return &NewFilterSubscriptionResult{
+		Height:    entry.Height,
+		BlockHash: entry.BlockHash,
+		Filter:    hex.EncodeToString(entry.Filter),
+		Header:    hex.EncodeToString(entry.Header),
+	}
+}
@@ -0,0 +1,52 @@
+package bchain
+
+import "testing"
+
+// buildFilterForBlock computes the BIP 158 basic filter for a single output
+// script, keyed the same way Generate keys a real block's filter: from the
+// block's own hash via BlockFilterKey
+func buildFilterForBlock(t *testing.T, blockHash string, ad AddressDescriptor) []byte {
+	t.Helper()
+	key, err := BlockFilterKey(blockHash)
+	if err != nil {
+		t.Fatalf("BlockFilterKey: %v", err)
+	}
+	gf, err := newGolombFilter(19, 784931, "", key)
+	if err != nil {
+		t.Fatalf("newGolombFilter: %v", err)
+	}
+	gf.AddAddrDesc(ad, nil)
+	return gf.Compute()
+}
+
+// TestScanBlocksUsesPerEntryKey guards against matching every scanned block
+// against one caller-supplied key: two blocks with distinct hashes each get a
+// filter built (and must be matched) with their own BlockFilterKey, not a
+// single key shared across the whole scan.
+func TestScanBlocksUsesPerEntryKey(t *testing.T) {
+	hashA := "1111111111111111111111111111111111111111111111111111111111111111"
+	hashB := "2222222222222222222222222222222222222222222222222222222222222222"
+	scriptA := p2wpkhScript()
+	scriptB := taprootScript()
+
+	entries := []*FilterHeaderEntry{
+		{FilterType: 0x00, BlockHash: hashA, Height: 1, Filter: buildFilterForBlock(t, hashA, scriptA)},
+		{FilterType: 0x00, BlockHash: hashB, Height: 2, Filter: buildFilterForBlock(t, hashB, scriptB)},
+	}
+
+	result, err := ScanBlocks(0x00, 19, 784931, []AddressDescriptor{scriptA}, entries)
+	if err != nil {
+		t.Fatalf("ScanBlocks: %v", err)
+	}
+	if len(result.Heights) != 1 || result.Heights[0] != 1 {
+		t.Errorf("Heights = %v, want [1]", result.Heights)
+	}
+
+	result, err = ScanBlocks(0x00, 19, 784931, []AddressDescriptor{scriptB}, entries)
+	if err != nil {
+		t.Fatalf("ScanBlocks: %v", err)
+	}
+	if len(result.Heights) != 1 || result.Heights[0] != 2 {
+		t.Errorf("Heights = %v, want [2]", result.Heights)
+	}
+}
@@ -0,0 +1,242 @@
+package bchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sync"
+
+	"github.com/juju/errors"
+	"github.com/martinboehm/btcd/chaincfg/chainhash"
+	"github.com/martinboehm/btcutil/gcs"
+)
+
+// This file and filterapi.go implement the bchain-level BIP 157 building
+// blocks: header computation, the FilterHeaderStore contract, a
+// MemFilterHeaderStore reference implementation exercising that contract,
+// and the request/response shapes for getblockfilter/getblockfilterheader(s).
+// A production deployment is expected to back FilterHeaderChain with a
+// RocksDB-backed FilterHeaderStore instead of MemFilterHeaderStore. Wiring
+// that store into db, registering the RPC/REST routes and the websocket
+// subscription in server, and calling ConnectBlock/DisconnectBlocksAbove from
+// block-connect/reorg code is follow-up work tracked separately from this
+// package.
+
+// FilterHeaderSize is the size in bytes of a BIP 157 filter header
+const FilterHeaderSize = 32
+
+// GenesisFilterHeader is the all-zero filter header used as the seed for
+// the filter header chain at genesis (block height 0 has no predecessor)
+var GenesisFilterHeader = make([]byte, FilterHeaderSize)
+
+// ComputeFilterHeader computes the BIP 157 filter header for a block, given
+// the raw serialized filter bytes and the filter header of the previous
+// block: header = DoubleSHA256( DoubleSHA256(filter) || prevHeader )
+func ComputeFilterHeader(filterBytes []byte, prevHeader []byte) []byte {
+	filterHash := chainhash.DoubleHashB(filterBytes)
+	return chainhash.DoubleHashB(append(filterHash, prevHeader...))
+}
+
+// BlockFilterKey derives the per-block SipHash key BIP 158 filters are built
+// and matched with: the first 16 bytes of the block hash, byte-reversed from
+// its usual display order into the hash's internal little-endian order.
+// Every block has its own filter key, so callers building or matching a
+// filter for a specific block must derive it from that block's hash rather
+// than reusing one key across several blocks
+func BlockFilterKey(blockHash string) (string, error) {
+	b, err := hex.DecodeString(blockHash)
+	if err != nil {
+		return "", errors.Annotate(err, "invalid block hash")
+	}
+	if len(b) < gcs.KeySize {
+		return "", errors.Errorf("block hash too short for a filter key: %d bytes", len(b))
+	}
+	le := make([]byte, len(b))
+	for i, c := range b {
+		le[len(b)-1-i] = c
+	}
+	return hex.EncodeToString(le[:gcs.KeySize]), nil
+}
+
+// FilterHeaderEntry is a single (filter, header) pair for a block and filter type
+type FilterHeaderEntry struct {
+	FilterType uint8
+	BlockHash  string
+	Height     uint32
+	Filter     []byte
+	Header     []byte
+}
+
+// FilterHeaderStore is the storage contract for persisting and retrieving
+// filters and their headers keyed by (filterType, blockHash); in this package
+// it is implemented by MemFilterHeaderStore
+type FilterHeaderStore interface {
+	// GetFilterHeader returns the stored filter header for the given block, if any
+	GetFilterHeader(filterType uint8, blockHash string) (header []byte, found bool, err error)
+	// GetFilter returns the stored raw filter bytes for the given block, if any
+	GetFilter(filterType uint8, blockHash string) (filter []byte, found bool, err error)
+	// StoreFilterHeader persists a (filter, header) pair for a connected block
+	StoreFilterHeader(entry *FilterHeaderEntry) error
+	// RollbackFilterHeaders removes stored filters/headers for blocks above the given height,
+	// used when walking back to the fork point during a reorg
+	RollbackFilterHeaders(filterType uint8, height uint32) error
+}
+
+// MemFilterHeaderStore is an in-memory FilterHeaderStore, used by tests and
+// by callers that don't need persistence across restarts; a real deployment
+// backs FilterHeaderChain with a RocksDB-backed implementation instead
+type MemFilterHeaderStore struct {
+	mux     sync.Mutex
+	entries map[uint8]map[string]*FilterHeaderEntry
+}
+
+// NewMemFilterHeaderStore initializes an empty MemFilterHeaderStore
+func NewMemFilterHeaderStore() *MemFilterHeaderStore {
+	return &MemFilterHeaderStore{
+		entries: make(map[uint8]map[string]*FilterHeaderEntry),
+	}
+}
+
+// GetFilterHeader returns the stored filter header for the given block, if any
+func (s *MemFilterHeaderStore) GetFilterHeader(filterType uint8, blockHash string) ([]byte, bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	entry, found := s.entries[filterType][blockHash]
+	if !found {
+		return nil, false, nil
+	}
+	return entry.Header, true, nil
+}
+
+// GetFilter returns the stored raw filter bytes for the given block, if any
+func (s *MemFilterHeaderStore) GetFilter(filterType uint8, blockHash string) ([]byte, bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	entry, found := s.entries[filterType][blockHash]
+	if !found {
+		return nil, false, nil
+	}
+	return entry.Filter, true, nil
+}
+
+// StoreFilterHeader persists a (filter, header) pair for a connected block
+func (s *MemFilterHeaderStore) StoreFilterHeader(entry *FilterHeaderEntry) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	byHash, found := s.entries[entry.FilterType]
+	if !found {
+		byHash = make(map[string]*FilterHeaderEntry)
+		s.entries[entry.FilterType] = byHash
+	}
+	byHash[entry.BlockHash] = entry
+	return nil
+}
+
+// RollbackFilterHeaders removes stored filters/headers for blocks above the given height
+func (s *MemFilterHeaderStore) RollbackFilterHeaders(filterType uint8, height uint32) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	byHash := s.entries[filterType]
+	for hash, entry := range byHash {
+		if entry.Height > height {
+			delete(byHash, hash)
+		}
+	}
+	return nil
+}
+
+// FilterHeaderSubscriber is notified when a new (filter, header) pair is
+// computed for the chain tip, so it can be pushed over a websocket subscription
+type FilterHeaderSubscriber interface {
+	NotifyNewFilterHeader(entry *FilterHeaderEntry)
+}
+
+// FilterHeaderChain maintains the BIP 157 filter header chain for a single
+// filter type, persisting it to a FilterHeaderStore as blocks connect and
+// recomputing it over reorged ranges
+type FilterHeaderChain struct {
+	filterType  uint8
+	store       FilterHeaderStore
+	subscribers []FilterHeaderSubscriber
+}
+
+// NewFilterHeaderChain initializes a FilterHeaderChain for the given filter type
+func NewFilterHeaderChain(filterType uint8, store FilterHeaderStore) *FilterHeaderChain {
+	return &FilterHeaderChain{
+		filterType: filterType,
+		store:      store,
+	}
+}
+
+// Subscribe registers a subscriber that is notified of new filter headers as the tip advances
+func (c *FilterHeaderChain) Subscribe(s FilterHeaderSubscriber) {
+	c.subscribers = append(c.subscribers, s)
+}
+
+// ConnectBlock computes the filter header for a newly connected block from its
+// raw filter bytes and the previous block's stored header, persists it and
+// notifies subscribers
+func (c *FilterHeaderChain) ConnectBlock(height uint32, blockHash string, prevBlockHash string, filter []byte) (*FilterHeaderEntry, error) {
+	prevHeader := GenesisFilterHeader
+	if height > 0 {
+		h, found, err := c.store.GetFilterHeader(c.filterType, prevBlockHash)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, errors.Errorf("missing filter header for previous block %s, filter type %d", prevBlockHash, c.filterType)
+		}
+		prevHeader = h
+	}
+	entry := &FilterHeaderEntry{
+		FilterType: c.filterType,
+		BlockHash:  blockHash,
+		Height:     height,
+		Filter:     filter,
+		Header:     ComputeFilterHeader(filter, prevHeader),
+	}
+	if err := c.store.StoreFilterHeader(entry); err != nil {
+		return nil, err
+	}
+	for _, s := range c.subscribers {
+		s.NotifyNewFilterHeader(entry)
+	}
+	return entry, nil
+}
+
+// DisconnectBlocksAbove prepares the filter header chain for a reorg by
+// dropping the stored headers/filters above forkHeight, so that the next
+// ConnectBlock call above the fork point chains from the fork point's header
+func (c *FilterHeaderChain) DisconnectBlocksAbove(forkHeight uint32) error {
+	return c.store.RollbackFilterHeaders(c.filterType, forkHeight)
+}
+
+// VerifyTip recomputes the filter headers for a window of blocks ending at
+// the current tip and compares them against the headers stored for those
+// blocks. heights and blockHashes/filters must be ordered oldest to newest;
+// anchorHeader is the filter header of the block preceding heights[0]
+// (GenesisFilterHeader if heights[0] is 0). It returns an error if any
+// computed header disagrees with the stored one, so the caller can refuse to
+// serve the BIP 157 endpoints over a chain of filter commitments it cannot
+// reproduce
+func (c *FilterHeaderChain) VerifyTip(anchorHeader []byte, heights []uint32, blockHashes []string, filters [][]byte) error {
+	if len(heights) != len(blockHashes) || len(heights) != len(filters) {
+		return errors.New("heights, blockHashes and filters must have the same length")
+	}
+	prevHeader := anchorHeader
+	for i, height := range heights {
+		computed := ComputeFilterHeader(filters[i], prevHeader)
+		stored, found, err := c.store.GetFilterHeader(c.filterType, blockHashes[i])
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errors.Errorf("cannot verify filter header chain: no stored header for block %s", blockHashes[i])
+		}
+		if !bytes.Equal(computed, stored) {
+			return errors.Errorf("filter header mismatch at height %d: computed %s, stored %s",
+				height, hex.EncodeToString(computed), hex.EncodeToString(stored))
+		}
+		prevHeader = computed
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package bchain
+
+import "testing"
+
+type recordingSubscriber struct {
+	notified []*FilterHeaderEntry
+}
+
+func (s *recordingSubscriber) NotifyNewFilterHeader(entry *FilterHeaderEntry) {
+	s.notified = append(s.notified, entry)
+}
+
+func TestFilterHeaderChainConnectAndVerify(t *testing.T) {
+	store := NewMemFilterHeaderStore()
+	chain := NewFilterHeaderChain(0x00, store)
+	sub := &recordingSubscriber{}
+	chain.Subscribe(sub)
+
+	hashes := []string{"hash0", "hash1", "hash2"}
+	filters := [][]byte{{0x01}, {0x02, 0x03}, {0x04}}
+
+	for i, hash := range hashes {
+		prev := ""
+		if i > 0 {
+			prev = hashes[i-1]
+		}
+		if _, err := chain.ConnectBlock(uint32(i), hash, prev, filters[i]); err != nil {
+			t.Fatalf("ConnectBlock(%d): %v", i, err)
+		}
+	}
+
+	if len(sub.notified) != len(hashes) {
+		t.Fatalf("subscriber notified %d times, want %d", len(sub.notified), len(hashes))
+	}
+
+	if err := chain.VerifyTip(GenesisFilterHeader, []uint32{0, 1, 2}, hashes, filters); err != nil {
+		t.Errorf("VerifyTip on an untouched chain should succeed: %v", err)
+	}
+
+	// reorging away height 2 and reconnecting a different block at that
+	// height must chain from height 1's header, not the discarded one
+	if err := chain.DisconnectBlocksAbove(1); err != nil {
+		t.Fatalf("DisconnectBlocksAbove: %v", err)
+	}
+	if _, found, _ := store.GetFilterHeader(0x00, hashes[2]); found {
+		t.Error("DisconnectBlocksAbove should have rolled back height 2's header")
+	}
+	replacementFilter := []byte{0xff}
+	if _, err := chain.ConnectBlock(2, "hash2b", hashes[1], replacementFilter); err != nil {
+		t.Fatalf("ConnectBlock after reorg: %v", err)
+	}
+	reorgedHashes := []string{hashes[0], hashes[1], "hash2b"}
+	reorgedFilters := [][]byte{filters[0], filters[1], replacementFilter}
+	if err := chain.VerifyTip(GenesisFilterHeader, []uint32{0, 1, 2}, reorgedHashes, reorgedFilters); err != nil {
+		t.Errorf("VerifyTip after reorg should succeed against the replacement block: %v", err)
+	}
+	if err := chain.VerifyTip(GenesisFilterHeader, []uint32{0, 1, 2}, hashes, filters); err == nil {
+		t.Error("VerifyTip should fail against the discarded (pre-reorg) height 2 header")
+	}
+}
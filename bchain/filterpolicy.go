@@ -0,0 +1,264 @@
+package bchain
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+
+	"github.com/juju/errors"
+)
+
+// FilterPolicy decides which outputs and transactions are represented in a
+// GolombFilter. Named policies are registered in the policy registry and can
+// be composed by a comma separated filterScripts configuration value, e.g.
+// "taproot,no-op-return" includes only taproot outputs that are not OP_RETURN;
+// GolombFilter.MetricsByPolicy reports, per named component of that
+// composition, how many outputs/transactions it rejected. Exposing the
+// composed filterScripts value as a config flag (and wiring
+// GolombFilter.MetricsByPolicy into the metrics registry) is follow-up work
+// tracked separately from this package.
+type FilterPolicy interface {
+	// IncludeOutput reports whether an output's address descriptor should be added to the filter
+	IncludeOutput(ad AddressDescriptor) bool
+	// IncludeTx reports whether outputs of tx may be added to the filter at all;
+	// returning false excludes the whole transaction (e.g. inscription envelopes)
+	IncludeTx(tx *Tx) bool
+}
+
+// FilterPolicyMetrics counts outputs/transactions a named policy rejected.
+// Each rejecting IncludeOutput/IncludeTx call counts once, so a rejected
+// transaction is counted once for every output added with it
+type FilterPolicyMetrics struct {
+	RejectedOutputs uint64
+	RejectedTxs     uint64
+}
+
+func (m *FilterPolicyMetrics) incOutputs() {
+	if m != nil {
+		atomic.AddUint64(&m.RejectedOutputs, 1)
+	}
+}
+
+func (m *FilterPolicyMetrics) incTxs() {
+	if m != nil {
+		atomic.AddUint64(&m.RejectedTxs, 1)
+	}
+}
+
+var filterPolicyRegistry = map[string]func(arg string) (FilterPolicy, error){
+	"": func(string) (FilterPolicy, error) { return allPolicy{}, nil },
+	"taproot": func(string) (FilterPolicy, error) { return taprootPolicy{}, nil },
+	"taproot-noordinals": func(string) (FilterPolicy, error) {
+		return taprootEnvelopePolicy{tag: "ord"}, nil
+	},
+	"taproot-noinscriptions": func(string) (FilterPolicy, error) {
+		return taprootEnvelopePolicy{}, nil
+	},
+	"segwit-v0-only": func(string) (FilterPolicy, error) { return segwitV0OnlyPolicy{}, nil },
+	"no-op-return": func(string) (FilterPolicy, error) { return noOpReturnPolicy{}, nil },
+}
+
+// RegisterFilterPolicy registers a named filter policy factory, so that
+// blockbook builds for a specific coin/deployment can add their own without
+// modifying this package. arg receives whatever follows the name after a
+// colon, e.g. "custom:/etc/blockbook/ord-policy.cel" passes arg
+// "/etc/blockbook/ord-policy.cel" to the "custom" factory
+func RegisterFilterPolicy(name string, factory func(arg string) (FilterPolicy, error)) {
+	filterPolicyRegistry[name] = factory
+}
+
+// CustomFilterPolicyLoader loads a policy from a file path, used for the
+// "custom:<path>" filterScripts entries. It is nil by default; a build that
+// wants to support Lua/CEL defined policies sets it at startup before
+// parsing configuration
+var CustomFilterPolicyLoader func(path string) (FilterPolicy, error)
+
+func init() {
+	RegisterFilterPolicy("custom", func(path string) (FilterPolicy, error) {
+		if CustomFilterPolicyLoader == nil {
+			return nil, errors.New("custom filter policies are not supported by this build (CustomFilterPolicyLoader is not set)")
+		}
+		return CustomFilterPolicyLoader(path)
+	})
+}
+
+// ParseFilterPolicy resolves a single "name" or "name:arg" entry against the
+// policy registry
+func ParseFilterPolicy(entry string) (FilterPolicy, error) {
+	name, arg := entry, ""
+	if i := strings.IndexByte(entry, ':'); i >= 0 {
+		name, arg = entry[:i], entry[i+1:]
+	}
+	factory, found := filterPolicyRegistry[name]
+	if !found {
+		return nil, errors.Errorf("Invalid/unsupported filterScripts parameter %s", entry)
+	}
+	return factory(arg)
+}
+
+// ComposeFilterPolicies parses a comma separated filterScripts configuration
+// value (e.g. "", "taproot", "taproot,no-op-return") into a single
+// *compositePolicy that requires every named component to accept an
+// output/transaction, and tracks each component's own rejection counts
+// (see GolombFilter.MetricsByPolicy)
+func ComposeFilterPolicies(filterScripts string) (FilterPolicy, error) {
+	names := []string{""}
+	if filterScripts != "" {
+		names = strings.Split(filterScripts, ",")
+	}
+	components := make([]namedPolicy, 0, len(names))
+	for _, name := range names {
+		p, err := ParseFilterPolicy(name)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, namedPolicy{name: name, policy: p})
+	}
+	return newCompositePolicy(components), nil
+}
+
+// namedPolicy pairs a FilterPolicy with the filterScripts entry that selected
+// it, so compositePolicy can attribute rejections back to it
+type namedPolicy struct {
+	name   string
+	policy FilterPolicy
+}
+
+// compositePolicy requires all of its component policies to accept the
+// output/transaction, implementing filterScripts values like
+// "taproot,no-op-return", while counting how many times each named component
+// rejected an output or transaction
+type compositePolicy struct {
+	components []namedPolicy
+	metrics    map[string]*FilterPolicyMetrics
+}
+
+func newCompositePolicy(components []namedPolicy) *compositePolicy {
+	metrics := make(map[string]*FilterPolicyMetrics, len(components))
+	for _, c := range components {
+		metrics[c.name] = &FilterPolicyMetrics{}
+	}
+	return &compositePolicy{components: components, metrics: metrics}
+}
+
+func (p *compositePolicy) IncludeOutput(ad AddressDescriptor) bool {
+	include := true
+	for _, c := range p.components {
+		if !c.policy.IncludeOutput(ad) {
+			p.metrics[c.name].incOutputs()
+			include = false
+		}
+	}
+	return include
+}
+
+func (p *compositePolicy) IncludeTx(tx *Tx) bool {
+	include := true
+	for _, c := range p.components {
+		if !c.policy.IncludeTx(tx) {
+			p.metrics[c.name].incTxs()
+			include = false
+		}
+	}
+	return include
+}
+
+// RejectedByPolicy returns a snapshot of each named component policy's
+// rejection counts, keyed by its filterScripts entry (e.g. "taproot")
+func (p *compositePolicy) RejectedByPolicy() map[string]FilterPolicyMetrics {
+	out := make(map[string]FilterPolicyMetrics, len(p.metrics))
+	for name, m := range p.metrics {
+		out[name] = FilterPolicyMetrics{
+			RejectedOutputs: atomic.LoadUint64(&m.RejectedOutputs),
+			RejectedTxs:     atomic.LoadUint64(&m.RejectedTxs),
+		}
+	}
+	return out
+}
+
+// IsSegwitV0 reports whether ad is a native segwit v0 output script
+// (P2WPKH: OP_0 <20 bytes>, or P2WSH: OP_0 <32 bytes>)
+func (ad AddressDescriptor) IsSegwitV0() bool {
+	if len(ad) != 22 && len(ad) != 34 {
+		return false
+	}
+	if ad[0] != 0x00 {
+		return false
+	}
+	return int(ad[1]) == len(ad)-2
+}
+
+// IsOpReturn reports whether ad is an OP_RETURN output script
+func (ad AddressDescriptor) IsOpReturn() bool {
+	return len(ad) > 0 && ad[0] == 0x6a
+}
+
+// allPolicy includes every output and transaction; it is the "" filterScripts value
+type allPolicy struct{}
+
+func (allPolicy) IncludeOutput(AddressDescriptor) bool { return true }
+func (allPolicy) IncludeTx(*Tx) bool                   { return true }
+
+// taprootPolicy includes only taproot outputs; it is the "taproot" filterScripts value
+type taprootPolicy struct{}
+
+func (taprootPolicy) IncludeOutput(ad AddressDescriptor) bool { return ad.IsTaproot() }
+func (taprootPolicy) IncludeTx(*Tx) bool                      { return true }
+
+// taprootEnvelopePolicy includes only taproot outputs and rejects whole
+// transactions whose witness carries an OP_FALSE OP_IF <tag> ... OP_ENDIF
+// envelope. An empty tag matches any envelope ("taproot-noinscriptions"),
+// a specific tag restricts to that protocol only ("ord" for "taproot-noordinals")
+type taprootEnvelopePolicy struct {
+	tag string
+}
+
+func (taprootEnvelopePolicy) IncludeOutput(ad AddressDescriptor) bool { return ad.IsTaproot() }
+
+func (p taprootEnvelopePolicy) IncludeTx(tx *Tx) bool {
+	// preserve the exact "ord" envelope detection already used by "taproot-noordinals"
+	if p.tag == "ord" {
+		return !txContainsOrdinal(tx)
+	}
+	for _, vin := range tx.Vin {
+		if inputHasEnvelope(vin, p.tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// segwitV0OnlyPolicy includes only native segwit v0 (P2WPKH/P2WSH) outputs
+type segwitV0OnlyPolicy struct{}
+
+func (segwitV0OnlyPolicy) IncludeOutput(ad AddressDescriptor) bool { return ad.IsSegwitV0() }
+func (segwitV0OnlyPolicy) IncludeTx(*Tx) bool                      { return true }
+
+// noOpReturnPolicy excludes OP_RETURN outputs, regardless of what else filters them
+type noOpReturnPolicy struct{}
+
+func (noOpReturnPolicy) IncludeOutput(ad AddressDescriptor) bool { return !ad.IsOpReturn() }
+func (noOpReturnPolicy) IncludeTx(*Tx) bool                      { return true }
+
+// envelopeOpcodes is the OP_FALSE OP_IF prefix shared by the "ord" envelope
+// and the generic inscription envelopes used by BRC-20 and similar protocols
+var envelopeOpcodes = []byte{0x00, 0x63} // OP_0/OP_FALSE, OP_IF
+
+// inputHasEnvelope reports whether vin has at least two witness items and the
+// second one starts with OP_FALSE OP_IF. With an empty tag that prefix alone
+// is a match; with a non-empty tag the tag's bytes must also appear somewhere
+// in that item. The tag push and the closing OP_ENDIF are not parsed, and an
+// envelope that does not start the second witness item is not detected.
+func inputHasEnvelope(vin Vin, tag string) bool {
+	if len(vin.Witness) < 2 {
+		return false
+	}
+	script := vin.Witness[1]
+	if !bytes.HasPrefix(script, envelopeOpcodes) {
+		return false
+	}
+	if tag == "" {
+		return true
+	}
+	return bytes.Contains(script, []byte(tag))
+}
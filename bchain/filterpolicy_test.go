@@ -0,0 +1,126 @@
+package bchain
+
+import "testing"
+
+// a well-formed P2TR scriptPubKey: OP_1 OP_PUSHBYTES_32 <32 bytes>
+func taprootScript() AddressDescriptor {
+	script := make([]byte, 34)
+	script[0] = 0x51
+	script[1] = 0x20
+	return AddressDescriptor(script)
+}
+
+func p2wpkhScript() AddressDescriptor {
+	script := make([]byte, 22)
+	script[0] = 0x00
+	script[1] = 0x14
+	return AddressDescriptor(script)
+}
+
+func opReturnScript() AddressDescriptor {
+	return AddressDescriptor([]byte{0x6a, 0x04, 't', 'e', 's', 't'})
+}
+
+func ordInscriptionTx() *Tx {
+	envelope := append([]byte{0x00, 0x63, 0x03, 'o', 'r', 'd', 0x01}, 0x00, 0x68) // OP_FALSE OP_IF "ord" ... OP_ENDIF
+	return &Tx{Vin: []Vin{{Witness: [][]byte{{}, envelope, {}}}}}
+}
+
+func genericInscriptionTx(tag string) *Tx {
+	envelope := append([]byte{0x00, 0x63, 0x03}, []byte(tag)...)
+	envelope = append(envelope, 0x01, 0x00, 0x68)
+	return &Tx{Vin: []Vin{{Witness: [][]byte{{}, envelope, {}}}}}
+}
+
+func TestAllPolicy(t *testing.T) {
+	p := allPolicy{}
+	if !p.IncludeOutput(taprootScript()) || !p.IncludeOutput(opReturnScript()) {
+		t.Error("allPolicy must include every output")
+	}
+	if !p.IncludeTx(ordInscriptionTx()) {
+		t.Error("allPolicy must include every transaction")
+	}
+}
+
+func TestTaprootPolicy(t *testing.T) {
+	p := taprootPolicy{}
+	if !p.IncludeOutput(taprootScript()) {
+		t.Error("taprootPolicy should include a taproot output")
+	}
+	if p.IncludeOutput(p2wpkhScript()) {
+		t.Error("taprootPolicy should exclude a non-taproot output")
+	}
+}
+
+func TestTaprootEnvelopePolicy(t *testing.T) {
+	ordOnly := taprootEnvelopePolicy{tag: "ord"}
+	if !ordOnly.IncludeTx(genericInscriptionTx("other")) {
+		t.Error("taproot-noordinals must not reject a non-ord envelope")
+	}
+	if ordOnly.IncludeTx(ordInscriptionTx()) {
+		t.Error("taproot-noordinals must reject an ord envelope")
+	}
+
+	noInscriptions := taprootEnvelopePolicy{}
+	if noInscriptions.IncludeTx(ordInscriptionTx()) {
+		t.Error("taproot-noinscriptions must reject an ord envelope")
+	}
+	if noInscriptions.IncludeTx(genericInscriptionTx("brc-20")) {
+		t.Error("taproot-noinscriptions must reject any tagged envelope")
+	}
+	if !noInscriptions.IncludeTx(&Tx{Vin: []Vin{{Witness: [][]byte{{}, {0x51}}}}}) {
+		t.Error("taproot-noinscriptions must include a transaction without an envelope")
+	}
+}
+
+func TestSegwitV0OnlyPolicy(t *testing.T) {
+	p := segwitV0OnlyPolicy{}
+	if !p.IncludeOutput(p2wpkhScript()) {
+		t.Error("segwit-v0-only should include a P2WPKH output")
+	}
+	if p.IncludeOutput(taprootScript()) {
+		t.Error("segwit-v0-only should exclude a taproot output")
+	}
+}
+
+func TestNoOpReturnPolicy(t *testing.T) {
+	p := noOpReturnPolicy{}
+	if p.IncludeOutput(opReturnScript()) {
+		t.Error("no-op-return should exclude an OP_RETURN output")
+	}
+	if !p.IncludeOutput(p2wpkhScript()) {
+		t.Error("no-op-return should include a non-OP_RETURN output")
+	}
+}
+
+func TestComposeFilterPoliciesInvalid(t *testing.T) {
+	if _, err := ComposeFilterPolicies("not-a-real-policy"); err == nil {
+		t.Error("expected an error for an unregistered filterScripts value")
+	}
+}
+
+func TestComposeFilterPoliciesAttributesMetricsPerPolicy(t *testing.T) {
+	policy, err := ComposeFilterPolicies("taproot,no-op-return")
+	if err != nil {
+		t.Fatalf("ComposeFilterPolicies: %v", err)
+	}
+	cp, ok := policy.(*compositePolicy)
+	if !ok {
+		t.Fatalf("ComposeFilterPolicies did not return a *compositePolicy")
+	}
+
+	// rejected only by "taproot"
+	policy.IncludeOutput(p2wpkhScript())
+	// rejected by both "taproot" (not a taproot output) and "no-op-return"
+	policy.IncludeOutput(opReturnScript())
+	// accepted by both
+	policy.IncludeOutput(taprootScript())
+
+	metrics := cp.RejectedByPolicy()
+	if metrics["taproot"].RejectedOutputs != 2 {
+		t.Errorf("taproot rejection count = %d, want 2", metrics["taproot"].RejectedOutputs)
+	}
+	if metrics["no-op-return"].RejectedOutputs != 1 {
+		t.Errorf("no-op-return rejection count = %d, want 1", metrics["no-op-return"].RejectedOutputs)
+	}
+}
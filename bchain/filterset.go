@@ -0,0 +1,84 @@
+package bchain
+
+import "github.com/juju/errors"
+
+// FilterSpec configures a single named filter type within a FilterSet, e.g.
+// the BIP 158 basic filter (type 0x00, P=19, M=784931, all scripts) or an
+// auxiliary taproot-only filter served alongside it. Calling
+// NewGolombFilterSet from the indexer's block-connect path with the
+// deployment's configured specs, and feeding FilterSet.ComputeAll's output
+// into a FilterHeaderChain per filterType, is follow-up work tracked
+// separately from this package (see FilterHeaderChain in filterheader.go).
+type FilterSpec struct {
+	FilterType    uint8
+	P             uint8
+	M             uint64 // 0 defaults to 1<<P
+	Key           string
+	FilterScripts string
+}
+
+// FilterSet maintains several named GolombFilter instances concurrently, so
+// that a single index run can build e.g. a "basic" and a "taproot" filter
+// for the same block without walking its outputs more than once
+type FilterSet struct {
+	filters     map[uint8]*GolombFilter
+	filterTypes []uint8
+}
+
+// NewGolombFilterSet initializes a GolombFilter for every spec, keyed by its FilterType
+func NewGolombFilterSet(specs []FilterSpec) (*FilterSet, error) {
+	fs := &FilterSet{
+		filters:     make(map[uint8]*GolombFilter, len(specs)),
+		filterTypes: make([]uint8, 0, len(specs)),
+	}
+	for _, spec := range specs {
+		if _, found := fs.filters[spec.FilterType]; found {
+			return nil, errors.Errorf("duplicate filter type %d in FilterSet", spec.FilterType)
+		}
+		gf, err := newGolombFilter(spec.P, spec.M, spec.FilterScripts, spec.Key)
+		if err != nil {
+			return nil, errors.Annotatef(err, "filter type %d", spec.FilterType)
+		}
+		fs.filters[spec.FilterType] = gf
+		fs.filterTypes = append(fs.filterTypes, spec.FilterType)
+	}
+	return fs, nil
+}
+
+// FilterTypes returns the filter types available in this set, in the order given to NewGolombFilterSet
+func (fs *FilterSet) FilterTypes() []uint8 {
+	return fs.filterTypes
+}
+
+// AddAddrDesc adds the address descriptor to every enabled filter in the set
+// whose own policy accepts it, so the caller only has to walk a block's
+// outputs once. Filter types configured with P == 0 (disabled) are skipped,
+// so a spec can be listed to reserve a filterType without indexing it
+func (fs *FilterSet) AddAddrDesc(ad AddressDescriptor, tx *Tx) {
+	for _, filterType := range fs.filterTypes {
+		gf := fs.filters[filterType]
+		if !gf.Enabled {
+			continue
+		}
+		gf.AddAddrDesc(ad, tx)
+	}
+}
+
+// Compute returns the serialized filter for the given filter type, or nil if
+// the type is not part of this set
+func (fs *FilterSet) Compute(filterType uint8) []byte {
+	gf, found := fs.filters[filterType]
+	if !found {
+		return nil
+	}
+	return gf.Compute()
+}
+
+// ComputeAll returns the serialized filter for every filter type in the set
+func (fs *FilterSet) ComputeAll() map[uint8][]byte {
+	result := make(map[uint8][]byte, len(fs.filterTypes))
+	for _, filterType := range fs.filterTypes {
+		result[filterType] = fs.filters[filterType].Compute()
+	}
+	return result
+}
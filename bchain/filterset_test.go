@@ -0,0 +1,51 @@
+package bchain
+
+import "testing"
+
+// TestFilterSetSkipsDisabledFilterType guards against the nil-policy panic
+// fixed in AddAddrDesc/FilterSet.AddAddrDesc: a FilterSpec with P == 0
+// (disabled) must be safely skipped, both on its own and alongside an
+// enabled filter type in the same set.
+func TestFilterSetSkipsDisabledFilterType(t *testing.T) {
+	specs := []FilterSpec{
+		{FilterType: 0x00, P: 19, M: 784931, Key: "00000000000000000000000000000000"},
+		{FilterType: 0x01, P: 0},
+	}
+	fs, err := NewGolombFilterSet(specs)
+	if err != nil {
+		t.Fatalf("NewGolombFilterSet: %v", err)
+	}
+
+	types := fs.FilterTypes()
+	if len(types) != 2 {
+		t.Fatalf("FilterTypes() = %v, want 2 entries", types)
+	}
+
+	// must not panic even though filter type 0x01 is disabled
+	fs.AddAddrDesc(taprootScript(), nil)
+
+	if fs.Compute(0x01) != nil {
+		t.Error("Compute on a disabled filter type should return nil")
+	}
+	if fs.Compute(0x02) != nil {
+		t.Error("Compute on an unknown filter type should return nil")
+	}
+
+	all := fs.ComputeAll()
+	if _, found := all[0x01]; !found {
+		t.Error("ComputeAll should still report an entry (nil) for a disabled filter type")
+	}
+	if all[0x01] != nil {
+		t.Error("ComputeAll entry for a disabled filter type should be nil")
+	}
+}
+
+func TestNewGolombFilterSetRejectsDuplicateFilterType(t *testing.T) {
+	specs := []FilterSpec{
+		{FilterType: 0x00, P: 19, M: 784931, Key: "00000000000000000000000000000000"},
+		{FilterType: 0x00, P: 19, M: 784931, Key: "00000000000000000000000000000000"},
+	}
+	if _, err := NewGolombFilterSet(specs); err == nil {
+		t.Error("expected an error for a duplicate filter type")
+	}
+}
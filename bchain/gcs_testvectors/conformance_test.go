@@ -0,0 +1,223 @@
+package gcs_testvectors
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/juju/errors"
+	"github.com/trezor/blockbook/bchain"
+)
+
+// fixtures/synthetic.csv is checked in: a couple of small, hand-built blocks
+// whose filter/header columns were computed directly against
+// github.com/martinboehm/btcutil/gcs, not pulled from a real chain, so this
+// subtest runs unconditionally. fixtures/mainnet.csv and fixtures/testnet.csv
+// are the real reference vectors, generated from a real node with Generate
+// against the canonical BIP 158 reference heights (mainnet: 0, 2, 3, 926485,
+// 987876, 1263442, 1414221, plus a few testnet heights); neither is present
+// in this tree yet, so those two subtests are skipped until the fixtures
+// exist, so that unverified, fabricated data is never mistaken for a passing
+// conformance check.
+var fixtureFiles = []string{
+	"fixtures/synthetic.csv",
+	"fixtures/mainnet.csv",
+	"fixtures/testnet.csv",
+}
+
+func TestGolombFilterConformance(t *testing.T) {
+	for _, path := range fixtureFiles {
+		path := path
+		t.Run(path, func(t *testing.T) {
+			rows, ok := readFixture(t, path)
+			if !ok {
+				t.Skipf("fixture %s not present; generate it with gcs_testvectors.Generate against a synced bitcoind before enabling this check", path)
+			}
+			for _, row := range rows {
+				row := row
+				t.Run("height_"+strconv.FormatUint(uint64(row.Height), 10), func(t *testing.T) {
+					verifyRow(t, row)
+				})
+			}
+		})
+	}
+}
+
+func verifyRow(t *testing.T, row Row) {
+	key, err := bchain.BlockFilterKey(row.BlockHash)
+	if err != nil {
+		t.Fatalf("BlockFilterKey: %v", err)
+	}
+	fs, err := bchain.NewGolombFilterSet([]bchain.FilterSpec{basicFilterSpec(key)})
+	if err != nil {
+		t.Fatalf("NewGolombFilterSet: %v", err)
+	}
+	for _, txv := range row.Txs {
+		tx := &bchain.Tx{Vin: make([]bchain.Vin, len(txv.VinWitness2))}
+		for i, w2 := range txv.VinWitness2 {
+			if txv.VinWitnessLen[i] == 0 {
+				continue
+			}
+			tx.Vin[i].Witness = make([][]byte, txv.VinWitnessLen[i])
+			if w2 != nil {
+				tx.Vin[i].Witness[1] = w2
+			}
+		}
+		for _, script := range txv.VoutScripts {
+			fs.AddAddrDesc(bchain.AddressDescriptor(script), tx)
+		}
+		for _, script := range txv.VinPrevScripts {
+			fs.AddAddrDesc(bchain.AddressDescriptor(script), tx)
+		}
+	}
+	filter := fs.Compute(filterType)
+	if got := hex.EncodeToString(filter); got != row.FilterHex {
+		t.Errorf("filter mismatch at height %d: got %s, want %s", row.Height, got, row.FilterHex)
+	}
+	prevHeader, err := hex.DecodeString(row.PrevFilterHeader)
+	if err != nil {
+		t.Fatalf("decoding prev_filter_header: %v", err)
+	}
+	header := bchain.ComputeFilterHeader(filter, prevHeader)
+	if got := hex.EncodeToString(header); got != row.FilterHeader {
+		t.Errorf("filter header mismatch at height %d: got %s, want %s", row.Height, got, row.FilterHeader)
+	}
+}
+
+// readFixture returns false (and no rows) if path does not exist, so tests
+// can skip cleanly instead of failing against data that was never generated
+func readFixture(t *testing.T, path string) ([]Row, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false
+		}
+		t.Fatalf("opening fixture %s: %v", path, err)
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.Comma = ';'
+	cr.Comment = '#'
+	records, err := cr.ReadAll()
+	if err != nil {
+		t.Fatalf("reading fixture %s: %v", path, err)
+	}
+
+	rows := make([]Row, 0, len(records))
+	for _, record := range records {
+		if len(record) != 11 {
+			t.Fatalf("fixture %s: expected 11 columns, got %d", path, len(record))
+		}
+		height, err := strconv.ParseUint(record[0], 10, 32)
+		if err != nil {
+			t.Fatalf("fixture %s: bad height %q: %v", path, record[0], err)
+		}
+		txCount, err := strconv.Atoi(record[3])
+		if err != nil {
+			t.Fatalf("fixture %s: bad tx_count %q: %v", path, record[3], err)
+		}
+		voutScripts, err := splitTxGroups(record[4], txCount)
+		if err != nil {
+			t.Fatalf("fixture %s: bad tx_vout_scripts: %v", path, err)
+		}
+		vinPrevScripts, err := splitTxGroups(record[5], txCount)
+		if err != nil {
+			t.Fatalf("fixture %s: bad tx_vin_prevscripts: %v", path, err)
+		}
+		vinWitness2, err := splitTxGroups(record[6], txCount)
+		if err != nil {
+			t.Fatalf("fixture %s: bad tx_vin_witness2: %v", path, err)
+		}
+		vinWitnessLen, err := splitIntTxGroups(record[7], txCount)
+		if err != nil {
+			t.Fatalf("fixture %s: bad tx_vin_witness_len: %v", path, err)
+		}
+		txs := make([]TxVectors, txCount)
+		for i := range txs {
+			txs[i] = TxVectors{
+				VoutScripts:    voutScripts[i],
+				VinPrevScripts: vinPrevScripts[i],
+				VinWitness2:    vinWitness2[i],
+				VinWitnessLen:  vinWitnessLen[i],
+			}
+		}
+		rows = append(rows, Row{
+			Height:           uint32(height),
+			BlockHash:        record[1],
+			BlockHeader:      record[2],
+			Txs:              txs,
+			PrevFilterHeader: record[8],
+			FilterHex:        record[9],
+			FilterHeader:     record[10],
+		})
+	}
+	return rows, true
+}
+
+// splitTxGroups parses the '|'-separated (transactions) / ','-separated
+// (items within a transaction) encoding written by joinTxGroups into exactly
+// txCount groups. An empty item hex-decodes to a nil entry (no second
+// witness item, for example). txCount disambiguates a field with zero
+// transactions from one transaction whose items are all empty, which both
+// serialize to the same "" string.
+func splitTxGroups(field string, txCount int) ([][][]byte, error) {
+	if field == "" {
+		return make([][][]byte, txCount), nil
+	}
+	groups := strings.Split(field, "|")
+	if len(groups) != txCount {
+		return nil, errors.Errorf("expected %d transactions, got %d", txCount, len(groups))
+	}
+	result := make([][][]byte, len(groups))
+	for i, group := range groups {
+		if group == "" {
+			continue
+		}
+		items := strings.Split(group, ",")
+		parsed := make([][]byte, len(items))
+		for j, item := range items {
+			if item == "" {
+				continue
+			}
+			b, err := hex.DecodeString(item)
+			if err != nil {
+				return nil, err
+			}
+			parsed[j] = b
+		}
+		result[i] = parsed
+	}
+	return result, nil
+}
+
+// splitIntTxGroups is splitTxGroups for per-input integer fields (e.g. witness stack length)
+func splitIntTxGroups(field string, txCount int) ([][]int, error) {
+	if field == "" {
+		return make([][]int, txCount), nil
+	}
+	groups := strings.Split(field, "|")
+	if len(groups) != txCount {
+		return nil, errors.Errorf("expected %d transactions, got %d", txCount, len(groups))
+	}
+	result := make([][]int, len(groups))
+	for i, group := range groups {
+		if group == "" {
+			continue
+		}
+		items := strings.Split(group, ",")
+		parsed := make([]int, len(items))
+		for j, item := range items {
+			n, err := strconv.Atoi(item)
+			if err != nil {
+				return nil, err
+			}
+			parsed[j] = n
+		}
+		result[i] = parsed
+	}
+	return result, nil
+}
@@ -0,0 +1,194 @@
+// Package gcs_testvectors generates and checks BIP 158 reference test
+// vectors for bchain.GolombFilter, so changes to the filter or policy code
+// can be regression tested against real mainnet/testnet blocks.
+package gcs_testvectors
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/trezor/blockbook/bchain"
+)
+
+// filterType is the BIP 158 basic filter type used by the reference vectors
+const filterType = 0x00
+
+// basicFilterSpec is the BIP 158 basic filter parameterization (P=19, M=784931);
+// the key is derived per block by bchain.BlockFilterKey
+func basicFilterSpec(key string) bchain.FilterSpec {
+	return bchain.FilterSpec{FilterType: filterType, P: 19, M: 784931, Key: key}
+}
+
+// BlockSource is the minimal subset of a chain RPC connection needed to
+// generate test vectors: resolving a height to the block and its previous
+// output scripts
+type BlockSource interface {
+	GetBlockHash(height uint32) (string, error)
+	GetBlockHeaderHex(hash string) (string, error)
+	// GetBlockWithPrevScripts returns the block at hash together with the
+	// scriptPubKey spent by each of its inputs, grouped per transaction:
+	// prevScripts[i][j] is the script spent by block.Txs[i].Vin[j]
+	GetBlockWithPrevScripts(hash string) (block *bchain.Block, prevScripts [][][]byte, err error)
+}
+
+// TxVectors captures the parts of one block transaction needed to exercise
+// GolombFilter's per-transaction policy checks (taproot/ordinal/inscription
+// exclusion): its own output scripts, the scriptPubKeys it spends, and the
+// length and second witness stack item of each input (where isInputOrdinal
+// and the generic inscription envelope check look for an OP_FALSE OP_IF
+// envelope, and isInputOrdinal additionally requires a witness of more than
+// two items)
+type TxVectors struct {
+	VoutScripts    [][]byte
+	VinPrevScripts [][]byte
+	VinWitness2    [][]byte // nil entry means that input had no second witness item
+	VinWitnessLen  []int    // len(vin.Witness), so a reconstructed witness preserves stack length
+}
+
+// Row is a single BIP 158 reference test vector
+type Row struct {
+	Height           uint32
+	BlockHash        string
+	BlockHeader      string
+	Txs              []TxVectors
+	PrevFilterHeader string
+	FilterHex        string
+	FilterHeader     string
+}
+
+// Generate produces one Row per requested height, computing the BIP 158
+// basic filter (P=19, M=784931, key = bchain.BlockFilterKey(hash)) over the
+// block's output scripts and the scriptPubkeys of the outputs it spends
+func Generate(src BlockSource, heights []uint32) ([]Row, error) {
+	rows := make([]Row, 0, len(heights))
+	prevHeader := hex.EncodeToString(bchain.GenesisFilterHeader)
+	for _, height := range heights {
+		hash, err := src.GetBlockHash(height)
+		if err != nil {
+			return nil, err
+		}
+		header, err := src.GetBlockHeaderHex(hash)
+		if err != nil {
+			return nil, err
+		}
+		block, prevScripts, err := src.GetBlockWithPrevScripts(hash)
+		if err != nil {
+			return nil, err
+		}
+		key, err := bchain.BlockFilterKey(hash)
+		if err != nil {
+			return nil, err
+		}
+		fs, err := bchain.NewGolombFilterSet([]bchain.FilterSpec{basicFilterSpec(key)})
+		if err != nil {
+			return nil, err
+		}
+		txVectors := make([]TxVectors, len(block.Txs))
+		for i := range block.Txs {
+			tx := &block.Txs[i]
+			voutScripts := make([][]byte, len(tx.Vout))
+			for j, vout := range tx.Vout {
+				script, err := hex.DecodeString(vout.ScriptPubKey.Hex)
+				if err != nil {
+					return nil, err
+				}
+				voutScripts[j] = script
+				fs.AddAddrDesc(bchain.AddressDescriptor(script), tx)
+			}
+			vinWitness2 := make([][]byte, len(tx.Vin))
+			vinWitnessLen := make([]int, len(tx.Vin))
+			for j, vin := range tx.Vin {
+				vinWitnessLen[j] = len(vin.Witness)
+				if len(vin.Witness) > 1 {
+					vinWitness2[j] = vin.Witness[1]
+				}
+			}
+			for _, script := range prevScripts[i] {
+				fs.AddAddrDesc(bchain.AddressDescriptor(script), tx)
+			}
+			txVectors[i] = TxVectors{
+				VoutScripts:    voutScripts,
+				VinPrevScripts: prevScripts[i],
+				VinWitness2:    vinWitness2,
+				VinWitnessLen:  vinWitnessLen,
+			}
+		}
+		filter := fs.Compute(filterType)
+		filterHeaderBytes, err := hex.DecodeString(prevHeader)
+		if err != nil {
+			return nil, err
+		}
+		filterHeader := bchain.ComputeFilterHeader(filter, filterHeaderBytes)
+		rows = append(rows, Row{
+			Height:           height,
+			BlockHash:        hash,
+			BlockHeader:      header,
+			Txs:              txVectors,
+			PrevFilterHeader: prevHeader,
+			FilterHex:        hex.EncodeToString(filter),
+			FilterHeader:     hex.EncodeToString(filterHeader),
+		})
+		prevHeader = hex.EncodeToString(filterHeader)
+	}
+	return rows, nil
+}
+
+// WriteCSV writes rows in the checked-in fixture format: one semicolon
+// separated row per block. The explicit tx_count field disambiguates a block
+// with zero transactions from one transaction whose per-transaction fields
+// are all empty, which would otherwise both serialize to "". Per-transaction
+// fields group transactions with '|' and the items within a transaction with ','.
+func WriteCSV(w io.Writer, rows []Row) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	for _, r := range rows {
+		record := []string{
+			strconv.FormatUint(uint64(r.Height), 10),
+			r.BlockHash,
+			r.BlockHeader,
+			strconv.Itoa(len(r.Txs)),
+			joinTxGroups(r.Txs, func(tx TxVectors) [][]byte { return tx.VoutScripts }),
+			joinTxGroups(r.Txs, func(tx TxVectors) [][]byte { return tx.VinPrevScripts }),
+			joinTxGroups(r.Txs, func(tx TxVectors) [][]byte { return tx.VinWitness2 }),
+			joinIntTxGroups(r.Txs, func(tx TxVectors) []int { return tx.VinWitnessLen }),
+			r.PrevFilterHeader,
+			r.FilterHex,
+			r.FilterHeader,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func joinTxGroups(txs []TxVectors, get func(TxVectors) [][]byte) string {
+	groups := make([]string, len(txs))
+	for i, tx := range txs {
+		items := get(tx)
+		parts := make([]string, len(items))
+		for j, item := range items {
+			parts[j] = hex.EncodeToString(item)
+		}
+		groups[i] = strings.Join(parts, ",")
+	}
+	return strings.Join(groups, "|")
+}
+
+// joinIntTxGroups is joinTxGroups for per-input integer fields (e.g. witness stack length)
+func joinIntTxGroups(txs []TxVectors, get func(TxVectors) []int) string {
+	groups := make([]string, len(txs))
+	for i, tx := range txs {
+		items := get(tx)
+		parts := make([]string, len(items))
+		for j, item := range items {
+			parts[j] = strconv.Itoa(item)
+		}
+		groups[i] = strings.Join(parts, ",")
+	}
+	return strings.Join(groups, "|")
+}
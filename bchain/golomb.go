@@ -9,6 +9,9 @@ import (
 	"github.com/martinboehm/btcutil/gcs"
 )
 
+// FilterScriptsType is retained so existing references to its constants keep
+// compiling; GolombFilter neither produces nor uses it, filterScripts is
+// resolved to a FilterPolicy (see ComposeFilterPolicies) instead
 type FilterScriptsType int
 
 const (
@@ -20,36 +23,69 @@ const (
 
 // GolombFilter is computing golomb filter of address descriptors
 type GolombFilter struct {
-	Enabled           bool
-	p                 uint8
-	key               string
-	filterScripts     string
-	filterScriptsType FilterScriptsType
-	filterData        [][]byte
-	uniqueData        map[string]struct{}
+	Enabled       bool
+	p             uint8
+	m             uint64
+	key           string
+	filterScripts string
+	policy        FilterPolicy
+	metrics       FilterPolicyMetrics
+	filterData    [][]byte
+	uniqueData    map[string]struct{}
 }
 
-// NewGolombFilter initializes the GolombFilter handler
+// NewGolombFilter initializes the GolombFilter handler. filterScripts selects
+// the FilterPolicy (or comma separated composition of policies, see
+// ComposeFilterPolicies) applied to every AddAddrDesc call. The false
+// positive rate parameter M defaults to 1<<p; use NewGolombFilterSet for
+// filter types that need an explicit M (e.g. the BIP 158 basic filter's M=784931)
 func NewGolombFilter(p uint8, filterScripts string, key string) (*GolombFilter, error) {
+	return newGolombFilter(p, 0, filterScripts, key)
+}
+
+func newGolombFilter(p uint8, m uint64, filterScripts string, key string) (*GolombFilter, error) {
 	if p == 0 {
 		return &GolombFilter{Enabled: false}, nil
 	}
+	if m == 0 {
+		m = uint64(1) << uint64(p)
+	}
+	policy, err := ComposeFilterPolicies(filterScripts)
+	if err != nil {
+		return nil, err
+	}
 	gf := GolombFilter{
-		Enabled:           true,
-		p:                 p,
-		key:               key,
-		filterScripts:     filterScripts,
-		filterScriptsType: filterScriptsToScriptsType(filterScripts),
-		filterData:        make([][]byte, 0),
-		uniqueData:        make(map[string]struct{}),
-	}
-	// only taproot and all is supported
-	if gf.filterScriptsType == FilterScriptsInvalid {
-		return nil, errors.Errorf("Invalid/unsupported filterScripts parameter %s", filterScripts)
+		Enabled:       true,
+		p:             p,
+		m:             m,
+		key:           key,
+		filterScripts: filterScripts,
+		policy:        policy,
+		filterData:    make([][]byte, 0),
+		uniqueData:    make(map[string]struct{}),
 	}
 	return &gf, nil
 }
 
+// Metrics returns the aggregate counts of outputs and transactions this
+// filter's policy has rejected so far
+func (f *GolombFilter) Metrics() FilterPolicyMetrics {
+	return f.metrics
+}
+
+// MetricsByPolicy returns the rejection counts of each named component of a
+// composed filterScripts value (see ComposeFilterPolicies), keyed by its
+// filterScripts entry, e.g. {"taproot": {...}, "no-op-return": {...}} for a
+// filter built with filterScripts "taproot,no-op-return". It is empty for a
+// policy that wasn't produced by ComposeFilterPolicies (a custom FilterPolicy
+// passed some other way)
+func (f *GolombFilter) MetricsByPolicy() map[string]FilterPolicyMetrics {
+	if cp, ok := f.policy.(*compositePolicy); ok {
+		return cp.RejectedByPolicy()
+	}
+	return map[string]FilterPolicyMetrics{}
+}
+
 // Checks whether this input contains ordinal data
 func isInputOrdinal(vin Vin) bool {
 	byte_pattern := []byte{
@@ -74,12 +110,18 @@ func txContainsOrdinal(tx *Tx) bool {
 	return false
 }
 
-// AddAddrDesc adds taproot address descriptor to the data for the filter
+// AddAddrDesc adds an address descriptor to the data for the filter, subject
+// to the GolombFilter's policy. It is a no-op on a disabled filter (p == 0)
 func (f *GolombFilter) AddAddrDesc(ad AddressDescriptor, tx *Tx) {
-	if f.ignoreNonTaproot() && !ad.IsTaproot() {
+	if !f.Enabled {
+		return
+	}
+	if tx != nil && !f.policy.IncludeTx(tx) {
+		f.metrics.incTxs()
 		return
 	}
-	if f.ignoreOrdinals() && tx != nil && txContainsOrdinal(tx) {
+	if !f.policy.IncludeOutput(ad) {
+		f.metrics.incOutputs()
 		return
 	}
 	if len(ad) == 0 {
@@ -94,7 +136,7 @@ func (f *GolombFilter) AddAddrDesc(ad AddressDescriptor, tx *Tx) {
 
 // Compute computes golomb filter from the data
 func (f *GolombFilter) Compute() []byte {
-	m := uint64(1 << uint64(f.p))
+	m := f.m
 
 	if len(f.filterData) == 0 {
 		return nil
@@ -120,30 +162,49 @@ func (f *GolombFilter) Compute() []byte {
 	return fb
 }
 
-func (f *GolombFilter) ignoreNonTaproot() bool {
-	switch f.filterScriptsType {
-	case FilterScriptsTaproot, FilterScriptsTaprootNoOrdinals:
-		return true
-	}
-	return false
+// Match reports whether the given address descriptor is a member of the
+// filter previously computed by Compute
+func (f *GolombFilter) Match(ad AddressDescriptor) (bool, error) {
+	return MatchFilter(f.Compute(), f.key, f.p, f.m, [][]byte{ad})
 }
 
-func (f *GolombFilter) ignoreOrdinals() bool {
-	switch f.filterScriptsType {
-	case FilterScriptsTaprootNoOrdinals:
-		return true
+// MatchAny reports whether any of the given address descriptors is a member
+// of the filter previously computed by Compute
+func (f *GolombFilter) MatchAny(ads []AddressDescriptor) (bool, error) {
+	items := make([][]byte, len(ads))
+	for i, ad := range ads {
+		items[i] = ad
 	}
-	return false
+	return MatchFilter(f.Compute(), f.key, f.p, f.m, items)
 }
 
-func filterScriptsToScriptsType(filterScripts string) FilterScriptsType {
-	switch filterScripts {
-	case "":
-		return FilterScriptsAll
-	case "taproot":
-		return FilterScriptsTaproot
-	case "taproot-noordinals":
-		return FilterScriptsTaprootNoOrdinals
+// MatchFilter decodes a previously stored golomb filter (as produced by
+// GolombFilter.Compute) and reports whether it matches any of items. p and m
+// must match the parameters the filter was built with for its filter type
+// (see FilterSpec). It lets a wallet check a served filter against its own
+// watch-set of address descriptors without rebuilding a GolombFilter, e.g.
+// when implementing BIP 157 client-side rescans against filters fetched from blockbook
+func MatchFilter(filterBytes []byte, key string, p uint8, m uint64, items [][]byte) (bool, error) {
+	if len(filterBytes) == 0 || len(items) == 0 {
+		return false, nil
+	}
+	b, err := hex.DecodeString(key)
+	if err != nil {
+		return false, errors.Annotate(err, "invalid filter key")
+	}
+	if len(b) < gcs.KeySize {
+		return false, errors.New("filter key too short")
+	}
+	if m == 0 {
+		m = uint64(1) << uint64(p)
+	}
+	filter, err := gcs.FromNBytes(p, m, filterBytes)
+	if err != nil {
+		return false, errors.Annotate(err, "cannot decode golomb filter")
+	}
+	key16 := *(*[gcs.KeySize]byte)(b[:gcs.KeySize])
+	if len(items) == 1 {
+		return filter.Match(key16, items[0])
 	}
-	return FilterScriptsInvalid
+	return filter.MatchAny(key16, items)
 }